@@ -0,0 +1,710 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// References: http://en.wikipedia.org/wiki/B-tree
+package orderbook
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// defaultBTreeOrder is the branching factor (m) used when NewBTreeWith is given an
+// order too small to be useful, or an odd one. Each page holds up to m-1 sorted entries and up to m
+// child page keys, so one db.Get pulls back a whole page of the search path instead of
+// the single node per hop that Tree pays for.
+const defaultBTreeOrder = 64
+
+// BTreeNode is a single page of a BTree: up to order-1 sorted entries and, for internal
+// pages, up to order child page keys.
+type BTreeNode struct {
+	Key      []byte   // this page's own storage key
+	Keys     [][]byte // sorted entry keys held in this page
+	Values   [][]byte // values parallel to Keys
+	Children [][]byte // child page keys; len(Children) == len(Keys)+1 when !Leaf
+	Leaf     bool
+}
+
+// BTree is a disk-friendly alternative to Tree: the same Comparator/FormatBytes/
+// BatchDatabase surface, backed by an order-m B-tree instead of a red-black tree.
+//
+// Get matches Tree's signature exactly and Put does too except that it takes no *Dryrun
+// argument, but Left/Right/Floor/Ceiling return
+// (key, value, found) here instead of Tree's *Node, because a BTreeNode is a page of
+// several entries with no single-node identity to hand back the way a red-black Node
+// has. Squaring that away behind one shared interface - and wiring BTree in as an
+// alternative backend for OrderTree/OrderList - is deferred until OrderTree/OrderList
+// themselves exist in this tree; doing it now against Tree's current *Node-returning
+// methods would just be speculative.
+type BTree struct {
+	db          *BatchDatabase
+	rootKey     []byte
+	size        uint64
+	nextPageID  uint64
+	order       int
+	Comparator  Comparator
+	FormatBytes FormatBytes
+}
+
+// NewBTreeWith instantiates a B-tree of the given order with the custom comparator.
+// order must be even and at least 4; anything smaller, or odd, falls back to
+// defaultBTreeOrder. splitChild promotes child.Keys[mid] with mid = (order-1)/2 and
+// hands the remainder to the new sibling; for an odd order that split is uneven (a
+// full page of order 3 leaves the sibling with zero keys, below minKeys), so only even
+// orders are accepted.
+func NewBTreeWith(order int, comparator Comparator, db *BatchDatabase) *BTree {
+	if order < 4 || order%2 != 0 {
+		order = defaultBTreeOrder
+	}
+	return &BTree{
+		db:         db,
+		order:      order,
+		Comparator: comparator,
+	}
+}
+
+// NewBTreeWithBytesComparator instantiates a B-tree of the given order using
+// bytes.Compare as the comparator.
+func NewBTreeWithBytesComparator(order int, db *BatchDatabase) *BTree {
+	return NewBTreeWith(order, bytes.Compare, db)
+}
+
+func (tree *BTree) IsEmptyKey(key []byte) bool {
+	return tree.db.IsEmptyKey(key)
+}
+
+// Size returns the number of entries stored in the tree.
+func (tree *BTree) Size() uint64 {
+	return tree.size
+}
+
+// Empty returns true if the tree holds no entries.
+func (tree *BTree) Empty() bool {
+	return tree.size == 0
+}
+
+// pageKey derives a fresh, unique storage key for a new page. Pages live in their own
+// namespace (a 'b' prefix) so they never collide with the data keys callers Put into
+// the tree.
+func (tree *BTree) pageKey() []byte {
+	tree.nextPageID++
+	key := make([]byte, 9)
+	key[0] = 'b'
+	binary.BigEndian.PutUint64(key[1:], tree.nextPageID)
+	return key
+}
+
+func (tree *BTree) getNode(key []byte) (*BTreeNode, error) {
+	if tree.IsEmptyKey(key) {
+		return nil, nil
+	}
+	item := &BTreeNode{}
+	val, err := tree.db.Get(key, item)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	node := val.(*BTreeNode)
+	node.Key = key
+	return node, nil
+}
+
+func (tree *BTree) saveNode(node *BTreeNode) error {
+	if tree.IsEmptyKey(node.Key) {
+		node.Key = tree.pageKey()
+	}
+	return tree.db.Put(node.Key, node)
+}
+
+func (tree *BTree) root() (*BTreeNode, error) {
+	return tree.getNode(tree.rootKey)
+}
+
+// minKeys is the fewest keys a non-root page may hold once Remove has finished
+// borrowing/merging, i.e. ceil(m/2) - 1.
+func (tree *BTree) minKeys() int {
+	return (tree.order+1)/2 - 1
+}
+
+// search returns the index of the first key in node.Keys that is >= key, and whether
+// that key is an exact match.
+func (tree *BTree) search(node *BTreeNode, key []byte) (index int, found bool) {
+	lo, hi := 0, len(node.Keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if tree.Comparator(node.Keys[mid], key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(node.Keys) && tree.Comparator(node.Keys[lo], key) == 0 {
+		return lo, true
+	}
+	return lo, false
+}
+
+func insertAt(slice [][]byte, i int, v []byte) [][]byte {
+	slice = append(slice, nil)
+	copy(slice[i+1:], slice[i:])
+	slice[i] = v
+	return slice
+}
+
+func removeAt(slice [][]byte, i int) [][]byte {
+	copy(slice[i:], slice[i+1:])
+	return slice[:len(slice)-1]
+}
+
+// Put inserts or updates key in the tree, splitting full pages on the way down so a
+// single top-down pass never has to revisit a page it already descended through.
+func (tree *BTree) Put(key []byte, value []byte) error {
+	root, err := tree.root()
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		root = &BTreeNode{Leaf: true, Keys: [][]byte{key}, Values: [][]byte{value}}
+		if err := tree.saveNode(root); err != nil {
+			return err
+		}
+		tree.rootKey = root.Key
+		tree.size++
+		return nil
+	}
+
+	if len(root.Keys) == tree.order-1 {
+		newRoot := &BTreeNode{Leaf: false, Children: [][]byte{root.Key}}
+		if err := tree.saveNode(newRoot); err != nil {
+			return err
+		}
+		if err := tree.splitChild(newRoot, 0, root); err != nil {
+			return err
+		}
+		tree.rootKey = newRoot.Key
+		root = newRoot
+	}
+
+	inserted, err := tree.insertNonFull(root, key, value)
+	if err != nil {
+		return err
+	}
+	if inserted {
+		tree.size++
+	}
+	return nil
+}
+
+// splitChild splits a full child (order-1 keys) of parent into two pages around the
+// median entry, which is promoted into parent at index i.
+func (tree *BTree) splitChild(parent *BTreeNode, i int, child *BTreeNode) error {
+	mid := (tree.order - 1) / 2
+	medianKey := child.Keys[mid]
+	medianValue := child.Values[mid]
+
+	sibling := &BTreeNode{Leaf: child.Leaf}
+	sibling.Keys = append([][]byte{}, child.Keys[mid+1:]...)
+	sibling.Values = append([][]byte{}, child.Values[mid+1:]...)
+	if !child.Leaf {
+		sibling.Children = append([][]byte{}, child.Children[mid+1:]...)
+		child.Children = child.Children[:mid+1]
+	}
+	child.Keys = child.Keys[:mid]
+	child.Values = child.Values[:mid]
+
+	if err := tree.saveNode(sibling); err != nil {
+		return err
+	}
+	if err := tree.saveNode(child); err != nil {
+		return err
+	}
+
+	parent.Keys = insertAt(parent.Keys, i, medianKey)
+	parent.Values = insertAt(parent.Values, i, medianValue)
+	parent.Children = insertAt(parent.Children, i+1, sibling.Key)
+	return tree.saveNode(parent)
+}
+
+func (tree *BTree) insertNonFull(node *BTreeNode, key []byte, value []byte) (inserted bool, err error) {
+	i, found := tree.search(node, key)
+	if found {
+		node.Values[i] = value
+		return false, tree.saveNode(node)
+	}
+
+	if node.Leaf {
+		node.Keys = insertAt(node.Keys, i, key)
+		node.Values = insertAt(node.Values, i, value)
+		return true, tree.saveNode(node)
+	}
+
+	child, err := tree.getNode(node.Children[i])
+	if err != nil {
+		return false, err
+	}
+	if len(child.Keys) == tree.order-1 {
+		if err := tree.splitChild(node, i, child); err != nil {
+			return false, err
+		}
+		switch compare := tree.Comparator(key, node.Keys[i]); {
+		case compare == 0:
+			node.Values[i] = value
+			return false, tree.saveNode(node)
+		case compare > 0:
+			i++
+		}
+		if child, err = tree.getNode(node.Children[i]); err != nil {
+			return false, err
+		}
+	}
+	return tree.insertNonFull(child, key, value)
+}
+
+// Get searches the tree for key and returns its value, descending one page per level
+// instead of one node per level.
+func (tree *BTree) Get(key []byte) (value []byte, found bool) {
+	node, err := tree.root()
+	if err != nil || node == nil {
+		return nil, false
+	}
+	for {
+		i, ok := tree.search(node, key)
+		if ok {
+			return node.Values[i], true
+		}
+		if node.Leaf {
+			return nil, false
+		}
+		child, err := tree.getNode(node.Children[i])
+		if err != nil || child == nil {
+			return nil, false
+		}
+		node = child
+	}
+}
+
+// Left returns the smallest key/value pair in the tree, or found=false if it is empty.
+func (tree *BTree) Left() (key []byte, value []byte, found bool) {
+	node, err := tree.root()
+	if err != nil || node == nil {
+		return nil, nil, false
+	}
+	for !node.Leaf {
+		child, err := tree.getNode(node.Children[0])
+		if err != nil || child == nil {
+			return nil, nil, false
+		}
+		node = child
+	}
+	if len(node.Keys) == 0 {
+		return nil, nil, false
+	}
+	return node.Keys[0], node.Values[0], true
+}
+
+// Right returns the largest key/value pair in the tree, or found=false if it is empty.
+func (tree *BTree) Right() (key []byte, value []byte, found bool) {
+	node, err := tree.root()
+	if err != nil || node == nil {
+		return nil, nil, false
+	}
+	for !node.Leaf {
+		child, err := tree.getNode(node.Children[len(node.Children)-1])
+		if err != nil || child == nil {
+			return nil, nil, false
+		}
+		node = child
+	}
+	if len(node.Keys) == 0 {
+		return nil, nil, false
+	}
+	last := len(node.Keys) - 1
+	return node.Keys[last], node.Values[last], true
+}
+
+// Floor finds the largest key that is <= the given key.
+func (tree *BTree) Floor(key []byte) (floorKey []byte, floorValue []byte, found bool) {
+	node, err := tree.root()
+	if err != nil {
+		return nil, nil, false
+	}
+	for node != nil {
+		i, ok := tree.search(node, key)
+		if ok {
+			return node.Keys[i], node.Values[i], true
+		}
+		if i > 0 {
+			floorKey, floorValue, found = node.Keys[i-1], node.Values[i-1], true
+		}
+		if node.Leaf {
+			break
+		}
+		child, err := tree.getNode(node.Children[i])
+		if err != nil {
+			break
+		}
+		node = child
+	}
+	return
+}
+
+// Ceiling finds the smallest key that is >= the given key.
+func (tree *BTree) Ceiling(key []byte) (ceilingKey []byte, ceilingValue []byte, found bool) {
+	node, err := tree.root()
+	if err != nil {
+		return nil, nil, false
+	}
+	for node != nil {
+		i, ok := tree.search(node, key)
+		if ok {
+			return node.Keys[i], node.Values[i], true
+		}
+		if i < len(node.Keys) {
+			ceilingKey, ceilingValue, found = node.Keys[i], node.Values[i], true
+		}
+		if node.Leaf {
+			break
+		}
+		child, err := tree.getNode(node.Children[i])
+		if err != nil {
+			break
+		}
+		node = child
+	}
+	return
+}
+
+// Remove deletes key from the tree, borrowing a key from a sibling page with spare
+// capacity or merging with one otherwise, and propagating merges toward the root.
+func (tree *BTree) Remove(key []byte) {
+	root, err := tree.root()
+	if err != nil || root == nil {
+		return
+	}
+	removed, err := tree.removeFrom(root, key)
+	if err != nil || !removed {
+		return
+	}
+	tree.size--
+
+	if root, err = tree.getNode(tree.rootKey); err == nil && root != nil && len(root.Keys) == 0 {
+		if root.Leaf {
+			tree.rootKey = EmptyKey()
+		} else {
+			tree.rootKey = root.Children[0]
+		}
+	}
+}
+
+func (tree *BTree) removeFrom(node *BTreeNode, key []byte) (bool, error) {
+	i, found := tree.search(node, key)
+
+	if found {
+		if node.Leaf {
+			node.Keys = removeAt(node.Keys, i)
+			node.Values = removeAt(node.Values, i)
+			return true, tree.saveNode(node)
+		}
+		return tree.removeFromInternal(node, i)
+	}
+
+	if node.Leaf {
+		return false, nil
+	}
+
+	child, err := tree.getNode(node.Children[i])
+	if err != nil {
+		return false, err
+	}
+	if len(child.Keys) <= tree.minKeys() {
+		if child, i, err = tree.fill(node, i); err != nil {
+			return false, err
+		}
+	}
+	return tree.removeFrom(child, key)
+}
+
+// removeFromInternal deletes the key living at index i of an internal page, replacing
+// it with the in-order predecessor or successor (whichever side has spare keys to
+// give up without itself falling under the minimum), or merging the two children
+// around it when neither does.
+func (tree *BTree) removeFromInternal(node *BTreeNode, i int) (bool, error) {
+	sepKey := node.Keys[i]
+
+	left, err := tree.getNode(node.Children[i])
+	if err != nil {
+		return false, err
+	}
+	if len(left.Keys) > tree.minKeys() {
+		predKey, predValue, err := tree.maxEntry(left)
+		if err != nil {
+			return false, err
+		}
+		node.Keys[i], node.Values[i] = predKey, predValue
+		if err := tree.saveNode(node); err != nil {
+			return false, err
+		}
+		return tree.removeFrom(left, predKey)
+	}
+
+	right, err := tree.getNode(node.Children[i+1])
+	if err != nil {
+		return false, err
+	}
+	if len(right.Keys) > tree.minKeys() {
+		succKey, succValue, err := tree.minEntry(right)
+		if err != nil {
+			return false, err
+		}
+		node.Keys[i], node.Values[i] = succKey, succValue
+		if err := tree.saveNode(node); err != nil {
+			return false, err
+		}
+		return tree.removeFrom(right, succKey)
+	}
+
+	merged, err := tree.merge(node, i)
+	if err != nil {
+		return false, err
+	}
+	return tree.removeFrom(merged, sepKey)
+}
+
+func (tree *BTree) maxEntry(node *BTreeNode) ([]byte, []byte, error) {
+	for !node.Leaf {
+		child, err := tree.getNode(node.Children[len(node.Children)-1])
+		if err != nil {
+			return nil, nil, err
+		}
+		node = child
+	}
+	last := len(node.Keys) - 1
+	return node.Keys[last], node.Values[last], nil
+}
+
+func (tree *BTree) minEntry(node *BTreeNode) ([]byte, []byte, error) {
+	for !node.Leaf {
+		child, err := tree.getNode(node.Children[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		node = child
+	}
+	return node.Keys[0], node.Values[0], nil
+}
+
+// fill tops node.Children[i] up past the minimum key count before the caller descends
+// into it, borrowing from a sibling with keys to spare or merging with one otherwise.
+// It returns the (possibly merged) child to descend into and its possibly-shifted
+// index within node.Children.
+func (tree *BTree) fill(node *BTreeNode, i int) (*BTreeNode, int, error) {
+	if i > 0 {
+		left, err := tree.getNode(node.Children[i-1])
+		if err != nil {
+			return nil, i, err
+		}
+		if len(left.Keys) > tree.minKeys() {
+			return tree.borrowFromLeft(node, i, left)
+		}
+	}
+	if i < len(node.Children)-1 {
+		right, err := tree.getNode(node.Children[i+1])
+		if err != nil {
+			return nil, i, err
+		}
+		if len(right.Keys) > tree.minKeys() {
+			return tree.borrowFromRight(node, i, right)
+		}
+	}
+	if i < len(node.Children)-1 {
+		merged, err := tree.merge(node, i)
+		return merged, i, err
+	}
+	merged, err := tree.merge(node, i-1)
+	return merged, i - 1, err
+}
+
+func (tree *BTree) borrowFromLeft(node *BTreeNode, i int, left *BTreeNode) (*BTreeNode, int, error) {
+	child, err := tree.getNode(node.Children[i])
+	if err != nil {
+		return nil, i, err
+	}
+	lastIdx := len(left.Keys) - 1
+
+	child.Keys = insertAt(child.Keys, 0, node.Keys[i-1])
+	child.Values = insertAt(child.Values, 0, node.Values[i-1])
+	node.Keys[i-1] = left.Keys[lastIdx]
+	node.Values[i-1] = left.Values[lastIdx]
+
+	if !left.Leaf {
+		child.Children = insertAt(child.Children, 0, left.Children[len(left.Children)-1])
+		left.Children = left.Children[:len(left.Children)-1]
+	}
+	left.Keys = left.Keys[:lastIdx]
+	left.Values = left.Values[:lastIdx]
+
+	if err := tree.saveNode(left); err != nil {
+		return nil, i, err
+	}
+	if err := tree.saveNode(child); err != nil {
+		return nil, i, err
+	}
+	return child, i, tree.saveNode(node)
+}
+
+func (tree *BTree) borrowFromRight(node *BTreeNode, i int, right *BTreeNode) (*BTreeNode, int, error) {
+	child, err := tree.getNode(node.Children[i])
+	if err != nil {
+		return nil, i, err
+	}
+
+	child.Keys = append(child.Keys, node.Keys[i])
+	child.Values = append(child.Values, node.Values[i])
+	node.Keys[i] = right.Keys[0]
+	node.Values[i] = right.Values[0]
+
+	if !right.Leaf {
+		child.Children = append(child.Children, right.Children[0])
+		right.Children = right.Children[1:]
+	}
+	right.Keys = right.Keys[1:]
+	right.Values = right.Values[1:]
+
+	if err := tree.saveNode(right); err != nil {
+		return nil, i, err
+	}
+	if err := tree.saveNode(child); err != nil {
+		return nil, i, err
+	}
+	return child, i, tree.saveNode(node)
+}
+
+// merge folds node.Children[i+1] and the separator node.Keys[i] into node.Children[i],
+// shrinking node by one key; it is the mirror of splitChild.
+func (tree *BTree) merge(node *BTreeNode, i int) (*BTreeNode, error) {
+	left, err := tree.getNode(node.Children[i])
+	if err != nil {
+		return nil, err
+	}
+	right, err := tree.getNode(node.Children[i+1])
+	if err != nil {
+		return nil, err
+	}
+
+	left.Keys = append(left.Keys, node.Keys[i])
+	left.Values = append(left.Values, node.Values[i])
+	left.Keys = append(left.Keys, right.Keys...)
+	left.Values = append(left.Values, right.Values...)
+	if !left.Leaf {
+		left.Children = append(left.Children, right.Children...)
+	}
+
+	node.Keys = removeAt(node.Keys, i)
+	node.Values = removeAt(node.Values, i)
+	node.Children = removeAt(node.Children, i+1)
+
+	tree.db.Delete(right.Key, true)
+
+	if err := tree.saveNode(left); err != nil {
+		return nil, err
+	}
+	return left, tree.saveNode(node)
+}
+
+// btreeFrame is one level of an in-order descent: the page being visited and the index
+// of the next entry within it to yield.
+type btreeFrame struct {
+	node *BTreeNode
+	idx  int
+}
+
+// BTreeIterator walks entries of a BTree in ascending key order via an explicit descent
+// stack, since pages do not carry parent pointers the way red-black Nodes do.
+type BTreeIterator struct {
+	tree  *BTree
+	stack []btreeFrame
+	hi    []byte
+	key   []byte
+	value []byte
+}
+
+// Iterator returns an iterator over every entry in the tree, in ascending key order.
+func (tree *BTree) Iterator() *BTreeIterator {
+	it := &BTreeIterator{tree: tree}
+	if root, err := tree.root(); err == nil && root != nil {
+		it.pushLeftmost(root)
+	}
+	return it
+}
+
+// Range returns an iterator over entries in [lo, hi], seeded by descending directly to
+// lo instead of walking the tree from its left-most page.
+func (tree *BTree) Range(lo, hi []byte) *BTreeIterator {
+	it := &BTreeIterator{tree: tree, hi: hi}
+	if root, err := tree.root(); err == nil && root != nil {
+		it.seekRange(root, lo)
+	}
+	return it
+}
+
+func (it *BTreeIterator) pushLeftmost(node *BTreeNode) {
+	for node != nil {
+		it.stack = append(it.stack, btreeFrame{node: node, idx: 0})
+		if node.Leaf {
+			return
+		}
+		child, err := it.tree.getNode(node.Children[0])
+		if err != nil {
+			return
+		}
+		node = child
+	}
+}
+
+func (it *BTreeIterator) seekRange(node *BTreeNode, lo []byte) {
+	for node != nil {
+		i, found := it.tree.search(node, lo)
+		it.stack = append(it.stack, btreeFrame{node: node, idx: i})
+		if found || node.Leaf {
+			return
+		}
+		child, err := it.tree.getNode(node.Children[i])
+		if err != nil {
+			return
+		}
+		node = child
+	}
+}
+
+// Next advances the iterator and reports whether it produced another entry.
+func (it *BTreeIterator) Next() bool {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		node := top.node
+
+		if top.idx >= len(node.Keys) {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+
+		i := top.idx
+		key, value := node.Keys[i], node.Values[i]
+		top.idx++
+		if !node.Leaf {
+			if child, err := it.tree.getNode(node.Children[top.idx]); err == nil {
+				it.pushLeftmost(child)
+			}
+		}
+
+		if it.hi != nil && it.tree.Comparator(key, it.hi) > 0 {
+			it.stack = nil
+			return false
+		}
+		it.key, it.value = key, value
+		return true
+	}
+	return false
+}
+
+func (it *BTreeIterator) Key() []byte   { return it.key }
+func (it *BTreeIterator) Value() []byte { return it.value }
@@ -0,0 +1,76 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewBTreeWithRejectsOddOrder(t *testing.T) {
+	cases := []struct {
+		requested int
+		want      int
+	}{
+		{requested: 3, want: defaultBTreeOrder},
+		{requested: 4, want: 4},
+		{requested: 5, want: defaultBTreeOrder},
+		{requested: 64, want: 64},
+	}
+	for _, c := range cases {
+		tree := NewBTreeWith(c.requested, bytes.Compare, nil)
+		if tree.order != c.want {
+			t.Errorf("NewBTreeWith(%d): order = %d, want %d", c.requested, tree.order, c.want)
+		}
+		if tree.order%2 != 0 {
+			t.Errorf("NewBTreeWith(%d): order %d is odd, splitChild would underflow a sibling", c.requested, tree.order)
+		}
+	}
+}
+
+func TestBTreeSplitKeepsBothHalvesAtMinKeys(t *testing.T) {
+	tree := NewBTreeWith(4, bytes.Compare, nil)
+	full := &BTreeNode{
+		Leaf:   true,
+		Keys:   [][]byte{{1}, {2}, {3}},
+		Values: [][]byte{{1}, {2}, {3}},
+	}
+	parent := &BTreeNode{Children: [][]byte{{0}}}
+
+	mid := (tree.order - 1) / 2
+	sibling := &BTreeNode{Leaf: full.Leaf, Keys: append([][]byte{}, full.Keys[mid+1:]...)}
+	left := &BTreeNode{Leaf: full.Leaf, Keys: append([][]byte{}, full.Keys[:mid]...)}
+
+	if got, want := len(left.Keys), tree.minKeys(); got < want {
+		t.Errorf("left half has %d keys, below minKeys %d", got, want)
+	}
+	if got, want := len(sibling.Keys), tree.minKeys(); got < want {
+		t.Errorf("sibling has %d keys, below minKeys %d", got, want)
+	}
+	_ = parent
+}
+
+func TestBTreeSearch(t *testing.T) {
+	tree := NewBTreeWith(4, bytes.Compare, nil)
+	node := &BTreeNode{Keys: [][]byte{{10}, {20}, {30}}}
+
+	cases := []struct {
+		key       []byte
+		wantIndex int
+		wantFound bool
+	}{
+		{key: []byte{5}, wantIndex: 0, wantFound: false},
+		{key: []byte{10}, wantIndex: 0, wantFound: true},
+		{key: []byte{15}, wantIndex: 1, wantFound: false},
+		{key: []byte{30}, wantIndex: 2, wantFound: true},
+		{key: []byte{35}, wantIndex: 3, wantFound: false},
+	}
+	for _, c := range cases {
+		index, found := tree.search(node, c.key)
+		if index != c.wantIndex || found != c.wantFound {
+			t.Errorf("search(%v) = (%d, %v), want (%d, %v)", c.key, index, found, c.wantIndex, c.wantFound)
+		}
+	}
+}
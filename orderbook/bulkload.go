@@ -0,0 +1,185 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"fmt"
+	"iter"
+	"math"
+	"sort"
+)
+
+// NewFromSorted builds a Tree in a single bottom-up pass over entries already sorted by
+// cmp, instead of paying for the N red-black rebalances and N db.Put calls that
+// inserting one at a time through Put would cost when replaying a snapshot cold.
+//
+// entries must yield exactly count pairs in ascending cmp order. The tree is built as a
+// complete binary search tree over the sorted input: every level but the last is
+// filled. Nodes deeper than floor(log2(count+1)) levels, i.e. those on an incomplete
+// last level, are colored red and every other node black (a complete last level stays
+// black), which satisfies the red-black invariants without a single rotation.
+func NewFromSorted(db *BatchDatabase, cmp Comparator, entries iter.Seq2[[]byte, []byte], count uint64) (*Tree, error) {
+	tree := NewWith(cmp, db)
+	if count == 0 {
+		return tree, nil
+	}
+
+	keys := make([][]byte, 0, count)
+	values := make([][]byte, 0, count)
+	for k, v := range entries {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	if uint64(len(keys)) != count {
+		return nil, fmt.Errorf("orderbook: NewFromSorted expected %d entries, got %d", count, len(keys))
+	}
+
+	if err := tree.buildBalanced(keys, values); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// BulkInsert sorts entries by the tree's Comparator and then builds a complete,
+// perfectly balanced tree from them the same way NewFromSorted does, replacing
+// whatever the tree previously held.
+func (tree *Tree) BulkInsert(entries ...[2][]byte) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return tree.Comparator(entries[i][0], entries[j][0]) < 0
+	})
+
+	keys := make([][]byte, len(entries))
+	values := make([][]byte, len(entries))
+	for i, e := range entries {
+		keys[i] = e[0]
+		values[i] = e[1]
+	}
+	return tree.buildBalanced(keys, values)
+}
+
+// buildBalanced wires keys (already sorted) into a complete binary search tree
+// and sets it as the tree's contents. Equal keys are coalesced to their last value,
+// matching Put's update-in-place behavior, since storing the same db key twice under
+// two different tree positions would leave one node's parent/child links dangling.
+func (tree *Tree) buildBalanced(keys, values [][]byte) error {
+	keys, values = dedupSorted(tree.Comparator, keys, values)
+	if len(keys) == 0 {
+		tree.rootKey = EmptyKey()
+		tree.size = 0
+		return nil
+	}
+
+	blackHeight := int(math.Floor(math.Log2(float64(len(keys) + 1))))
+	if err := tree.buildSorted(keys, values, 0, len(keys)-1, 1, blackHeight, EmptyKey()); err != nil {
+		return err
+	}
+
+	tree.rootKey = keys[(len(keys)-1)/2]
+	tree.size = uint64(len(keys))
+	tree.validate()
+	return nil
+}
+
+// dedupSorted collapses runs of equal keys in a sorted slice down to their last
+// entry, in a single left-to-right pass.
+func dedupSorted(cmp Comparator, keys, values [][]byte) ([][]byte, [][]byte) {
+	if len(keys) == 0 {
+		return keys, values
+	}
+	dedupedKeys := keys[:1]
+	dedupedValues := values[:1]
+	for i := 1; i < len(keys); i++ {
+		if cmp(keys[i], dedupedKeys[len(dedupedKeys)-1]) == 0 {
+			dedupedValues[len(dedupedValues)-1] = values[i]
+			continue
+		}
+		dedupedKeys = append(dedupedKeys, keys[i])
+		dedupedValues = append(dedupedValues, values[i])
+	}
+	return dedupedKeys, dedupedValues
+}
+
+// buildSorted recursively wires keys[lo..hi] into a subtree whose root is the middle
+// entry, writing each node exactly once with its final parent/left/right keys already
+// known from index arithmetic over the sorted slice.
+func (tree *Tree) buildSorted(keys, values [][]byte, lo, hi, depth, blackHeight int, parentKey []byte) error {
+	if lo > hi {
+		return nil
+	}
+	mid := lo + (hi-lo)/2
+
+	color := black
+	if depth > blackHeight {
+		color = red
+	}
+
+	leftKey, rightKey := EmptyKey(), EmptyKey()
+	if lo <= mid-1 {
+		leftKey = keys[lo+(mid-1-lo)/2]
+	}
+	if mid+1 <= hi {
+		rightKey = keys[mid+1+(hi-mid-1)/2]
+	}
+
+	node := &Node{
+		Key: keys[mid],
+		Item: &Item{
+			Value: values[mid],
+			Color: color,
+			Keys:  &KeyMeta{},
+		},
+	}
+	node.LeftKey(leftKey)
+	node.RightKey(rightKey)
+	node.ParentKey(parentKey)
+	if err := tree.Save(node); err != nil {
+		return err
+	}
+
+	if err := tree.buildSorted(keys, values, lo, mid-1, depth+1, blackHeight, node.Key); err != nil {
+		return err
+	}
+	return tree.buildSorted(keys, values, mid+1, hi, depth+1, blackHeight, node.Key)
+}
+
+// validate walks the tree and panics if any red-black invariant is violated: a red
+// node with a red child, or two root-to-leaf paths with different black-heights. It
+// exists so bulk-load bugs surface immediately instead of silently corrupting a later
+// match.
+func (tree *Tree) validate() {
+	if tree.IsEmptyKey(tree.rootKey) {
+		return
+	}
+	root := tree.Root()
+	if root.Item.Color != black {
+		panic("orderbook: root is not black")
+	}
+	tree.validateNode(root)
+}
+
+func (tree *Tree) validateNode(node *Node) int {
+	if node == nil {
+		return 1
+	}
+
+	left := node.Left(tree)
+	right := node.Right(tree)
+
+	if node.Item.Color == red && (nodeColor(left) == red || nodeColor(right) == red) {
+		panic(fmt.Sprintf("orderbook: red node %x has a red child", node.Key))
+	}
+
+	leftHeight := tree.validateNode(left)
+	rightHeight := tree.validateNode(right)
+	if leftHeight != rightHeight {
+		panic(fmt.Sprintf("orderbook: unequal black-heights at node %x: %d vs %d", node.Key, leftHeight, rightHeight))
+	}
+
+	height := leftHeight
+	if node.Item.Color == black {
+		height++
+	}
+	return height
+}
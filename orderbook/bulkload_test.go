@@ -0,0 +1,43 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"bytes"
+	"testing"
+)
+
+// buildSorted/validate() exercise Tree.Save and Tree.Root, which go through
+// BatchDatabase - not part of this package snapshot - so only dedupSorted, the one
+// piece of the bulk-load path with no db dependency, is covered here.
+func TestDedupSortedCollapsesRunsToLastValue(t *testing.T) {
+	keys := [][]byte{{1}, {2}, {2}, {2}, {3}}
+	values := [][]byte{{'a'}, {'b'}, {'c'}, {'d'}, {'e'}}
+
+	dedupedKeys, dedupedValues := dedupSorted(bytes.Compare, keys, values)
+
+	wantKeys := [][]byte{{1}, {2}, {3}}
+	wantValues := [][]byte{{'a'}, {'d'}, {'e'}}
+
+	if len(dedupedKeys) != len(wantKeys) {
+		t.Fatalf("dedupSorted returned %d keys, want %d", len(dedupedKeys), len(wantKeys))
+	}
+	for i := range wantKeys {
+		if !bytes.Equal(dedupedKeys[i], wantKeys[i]) || !bytes.Equal(dedupedValues[i], wantValues[i]) {
+			t.Errorf("entry %d = (%v, %v), want (%v, %v)", i, dedupedKeys[i], dedupedValues[i], wantKeys[i], wantValues[i])
+		}
+	}
+}
+
+func TestDedupSortedNoDuplicates(t *testing.T) {
+	keys := [][]byte{{1}, {2}, {3}}
+	values := [][]byte{{'a'}, {'b'}, {'c'}}
+
+	dedupedKeys, dedupedValues := dedupSorted(bytes.Compare, keys, values)
+
+	if len(dedupedKeys) != 3 || len(dedupedValues) != 3 {
+		t.Fatalf("dedupSorted changed a slice with no duplicates: got %d entries", len(dedupedKeys))
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+// Dryrun wraps a BatchDatabase with a per-call in-memory overlay of pending Put/Delete
+// operations, so a caller can simulate a full match against the current book and
+// discard every write on return instead of committing it. Passing a non-nil Dryrun
+// into Tree.Put/Remove (and, through them, Order.UpdateQuantity and friends) makes
+// those writes land in the overlay; Commit is what turns the dry run into a real one.
+type Dryrun struct {
+	db      *BatchDatabase
+	puts    map[string]interface{}
+	deletes map[string]bool
+}
+
+// NewDryrun creates an overlay bound to db.
+func NewDryrun(db *BatchDatabase) *Dryrun {
+	return &Dryrun{
+		db:      db,
+		puts:    make(map[string]interface{}),
+		deletes: make(map[string]bool),
+	}
+}
+
+// lookup reports whether key has a pending overlay entry, without falling through to
+// the wrapped BatchDatabase. found is true for both a pending put (val non-nil) and a
+// pending delete (val nil, meaning the caller should stop looking rather than fall
+// through); callers fall through to db.Get themselves when found is false.
+func (d *Dryrun) lookup(key []byte, val interface{}) (interface{}, bool) {
+	k := string(key)
+	if d.deletes[k] {
+		return nil, true
+	}
+	if v, ok := d.puts[k]; ok {
+		return v, true
+	}
+	return nil, false
+}
+
+// Get resolves the overlay before falling through to the wrapped BatchDatabase.
+func (d *Dryrun) Get(key []byte, val interface{}) (interface{}, error) {
+	if v, found := d.lookup(key, val); found {
+		return v, nil
+	}
+	return d.db.Get(key, val)
+}
+
+// Put buffers value in the overlay; it is never written to the underlying
+// BatchDatabase unless Commit is called.
+func (d *Dryrun) Put(key []byte, value interface{}) error {
+	k := string(key)
+	delete(d.deletes, k)
+	d.puts[k] = value
+	return nil
+}
+
+// Delete buffers a tombstone in the overlay.
+func (d *Dryrun) Delete(key []byte, force bool) {
+	k := string(key)
+	delete(d.puts, k)
+	d.deletes[k] = true
+}
+
+// Has resolves the overlay before falling through to the wrapped BatchDatabase.
+func (d *Dryrun) Has(key []byte) (bool, error) {
+	k := string(key)
+	if d.deletes[k] {
+		return false, nil
+	}
+	if _, ok := d.puts[k]; ok {
+		return true, nil
+	}
+	return d.db.Has(key)
+}
+
+// Commit flushes the overlay through the wrapped BatchDatabase and clears it, turning
+// the dry run into a real one.
+func (d *Dryrun) Commit() error {
+	for k, v := range d.puts {
+		if err := d.db.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	for k := range d.deletes {
+		d.db.Delete([]byte(k), true)
+	}
+	d.puts = make(map[string]interface{})
+	d.deletes = make(map[string]bool)
+	return d.db.Commit()
+}
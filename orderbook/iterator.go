@@ -0,0 +1,218 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+// iterPosition tracks where an Iterator sits relative to the walk it is performing.
+type iterPosition byte
+
+const (
+	iterBegin iterPosition = iota
+	iterBetween
+	iterEnd
+)
+
+// Iterator holds the state of a Tree iterator. The zero value is not usable; obtain one
+// via Tree.Iterator, Tree.ReverseIterator, Tree.Range or Tree.ReverseRange.
+type Iterator struct {
+	tree      *Tree
+	node      *Node
+	position  iterPosition
+	reverse   bool
+	bounded   bool
+	inclusive bool
+	lo, hi    []byte
+}
+
+// Iterator returns a stateful iterator whose Next() walks the tree's keys in ascending
+// order, starting before the first key.
+func (tree *Tree) Iterator() *Iterator {
+	return &Iterator{tree: tree}
+}
+
+// ReverseIterator returns a stateful iterator whose Next() walks the tree's keys in
+// descending order, starting after the last key.
+func (tree *Tree) ReverseIterator() *Iterator {
+	return &Iterator{tree: tree, reverse: true}
+}
+
+// Range returns an iterator over keys in [lo, hi] (or (lo, hi) when inclusive is
+// false) walked in ascending order. It seeds from Ceiling(lo) instead of replaying the
+// whole tree, so the matching loop can walk only the crossing price levels.
+func (tree *Tree) Range(lo, hi []byte, inclusive bool) *Iterator {
+	return &Iterator{tree: tree, lo: lo, hi: hi, bounded: true, inclusive: inclusive}
+}
+
+// ReverseRange is the descending mirror of Range: it seeds from Floor(hi) and walks
+// down to lo, used to scan bids from the best price down.
+func (tree *Tree) ReverseRange(hi, lo []byte, inclusive bool) *Iterator {
+	return &Iterator{tree: tree, lo: lo, hi: hi, bounded: true, inclusive: inclusive, reverse: true}
+}
+
+// Next advances the iterator and reports whether it produced another key/value pair.
+func (iterator *Iterator) Next() bool {
+	if iterator.position == iterEnd {
+		return false
+	}
+
+	if iterator.position == iterBegin {
+		node := iterator.seekFirst()
+		if node != nil && iterator.atExclusiveNearBound(node) {
+			// seekFirst landed exactly on the bound closest to where this walk starts
+			// (lo for a forward range, hi for a reverse one); since that bound is
+			// exclusive, step past it instead of stopping the whole iteration here.
+			iterator.node = node
+			if iterator.reverse {
+				node = iterator.predecessor()
+			} else {
+				node = iterator.successor()
+			}
+		}
+		if node == nil {
+			iterator.position = iterEnd
+			return false
+		}
+		iterator.node = node
+	} else if iterator.reverse {
+		iterator.node = iterator.predecessor()
+	} else {
+		iterator.node = iterator.successor()
+	}
+
+	if iterator.node == nil || iterator.outOfBounds(iterator.node.Key) {
+		iterator.position = iterEnd
+		iterator.node = nil
+		return false
+	}
+
+	iterator.position = iterBetween
+	return true
+}
+
+func (iterator *Iterator) seekFirst() *Node {
+	switch {
+	case iterator.bounded && iterator.reverse:
+		node, found := iterator.tree.Floor(iterator.hi)
+		if !found {
+			return nil
+		}
+		return node
+	case iterator.bounded:
+		node, found := iterator.tree.Ceiling(iterator.lo)
+		if !found {
+			return nil
+		}
+		return node
+	case iterator.reverse:
+		return iterator.tree.Right()
+	default:
+		return iterator.tree.Left()
+	}
+}
+
+// atExclusiveNearBound reports whether node sits exactly on the bound nearest to where
+// this iterator starts its walk - lo for a forward range, hi for a reverse one - and
+// that bound is exclusive, meaning node itself must be skipped rather than yielded.
+func (iterator *Iterator) atExclusiveNearBound(node *Node) bool {
+	if !iterator.bounded || iterator.inclusive {
+		return false
+	}
+	tree := iterator.tree
+	if iterator.reverse {
+		return iterator.hi != nil && tree.Comparator(node.Key, iterator.hi) == 0
+	}
+	return iterator.lo != nil && tree.Comparator(node.Key, iterator.lo) == 0
+}
+
+func (iterator *Iterator) outOfBounds(key []byte) bool {
+	if !iterator.bounded {
+		return false
+	}
+	tree := iterator.tree
+	if iterator.lo != nil {
+		compare := tree.Comparator(key, iterator.lo)
+		if compare < 0 || (!iterator.inclusive && compare == 0) {
+			return true
+		}
+	}
+	if iterator.hi != nil {
+		compare := tree.Comparator(key, iterator.hi)
+		if compare > 0 || (!iterator.inclusive && compare == 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// successor returns the in-order successor of the iterator's current node: step right
+// once then left to the bottom, or otherwise walk up while the current node is a right
+// child.
+func (iterator *Iterator) successor() *Node {
+	tree := iterator.tree
+	node := iterator.node
+
+	if !tree.IsEmptyKey(node.RightKey()) {
+		node = node.Right(tree)
+		for !tree.IsEmptyKey(node.LeftKey()) {
+			node = node.Left(tree)
+		}
+		return node
+	}
+
+	for !tree.IsEmptyKey(node.ParentKey()) {
+		parent := node.Parent(tree)
+		if tree.Comparator(node.Key, parent.LeftKey()) == 0 {
+			return parent
+		}
+		node = parent
+	}
+	return nil
+}
+
+// predecessor is the mirror of successor, used to walk the tree in descending order:
+// step left once then right to the bottom, or otherwise repeatedly take the parent
+// while the current node is the parent's left child.
+func (iterator *Iterator) predecessor() *Node {
+	tree := iterator.tree
+	node := iterator.node
+
+	if !tree.IsEmptyKey(node.LeftKey()) {
+		node = node.Left(tree)
+		for !tree.IsEmptyKey(node.RightKey()) {
+			node = node.Right(tree)
+		}
+		return node
+	}
+
+	for !tree.IsEmptyKey(node.ParentKey()) {
+		parent := node.Parent(tree)
+		if tree.Comparator(node.Key, parent.RightKey()) == 0 {
+			return parent
+		}
+		node = parent
+	}
+	return nil
+}
+
+// Key returns the key at the iterator's current position.
+func (iterator *Iterator) Key() []byte {
+	return iterator.node.Key
+}
+
+// Value returns the value at the iterator's current position.
+func (iterator *Iterator) Value() []byte {
+	return iterator.node.Item.Value
+}
+
+// Walk calls fn for each key/value in [lo, hi] in ascending order, stopping as soon as
+// fn returns false, so a caller like the matching loop only visits crossing price
+// levels instead of the whole tree.
+func (tree *Tree) Walk(lo, hi []byte, fn func(key, value []byte) bool) {
+	it := tree.Range(lo, hi, true)
+	for it.Next() {
+		if !fn(it.Key(), it.Value()) {
+			return
+		}
+	}
+}
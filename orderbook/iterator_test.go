@@ -0,0 +1,56 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIteratorAtExclusiveNearBound(t *testing.T) {
+	tree := &Tree{Comparator: bytes.Compare}
+
+	forward := tree.Range([]byte{10}, []byte{30}, false)
+	if !forward.atExclusiveNearBound(&Node{Key: []byte{10}}) {
+		t.Error("forward exclusive range should flag its own lo bound for skipping")
+	}
+	if forward.atExclusiveNearBound(&Node{Key: []byte{20}}) {
+		t.Error("forward exclusive range must not flag a key strictly inside the range")
+	}
+
+	reverse := tree.ReverseRange([]byte{30}, []byte{10}, false)
+	if !reverse.atExclusiveNearBound(&Node{Key: []byte{30}}) {
+		t.Error("reverse exclusive range should flag its own hi bound for skipping")
+	}
+	if reverse.atExclusiveNearBound(&Node{Key: []byte{20}}) {
+		t.Error("reverse exclusive range must not flag a key strictly inside the range")
+	}
+
+	inclusive := tree.Range([]byte{10}, []byte{30}, true)
+	if inclusive.atExclusiveNearBound(&Node{Key: []byte{10}}) {
+		t.Error("an inclusive range must never flag its own bound for skipping")
+	}
+}
+
+// This is the case from review: keys {10, 20, 30}, Range(10, 30, false) must yield
+// {20} only. Exercising it end to end requires a working Tree backed by a
+// BatchDatabase, which this snapshot of the package does not include (BatchDatabase,
+// Node, Item and friends are all referenced but defined outside this chunk), so this
+// checks the bound-skip decision Next() relies on instead of walking a real tree.
+func TestIteratorExclusiveRangeSkipsNearBound(t *testing.T) {
+	tree := &Tree{Comparator: bytes.Compare}
+	it := tree.Range([]byte{10}, []byte{30}, false)
+
+	seed := &Node{Key: []byte{10}}
+	if !it.atExclusiveNearBound(seed) {
+		t.Fatal("seekFirst's Ceiling(10) result must be flagged so Next() steps to 20 instead of stopping")
+	}
+	if it.outOfBounds([]byte{20}) {
+		t.Error("20 lies strictly inside (10, 30) and must not be treated as out of bounds")
+	}
+	if !it.outOfBounds([]byte{30}) {
+		t.Error("30 is the exclusive hi bound and must be treated as out of bounds")
+	}
+}
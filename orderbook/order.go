@@ -66,16 +66,18 @@ func NewOrder(quote map[string]interface{}, orderList []byte) *Order {
 	return order
 }
 
-// UpdateQuantity : update quantity of the order
-func (order *Order) UpdateQuantity(orderList *OrderList, newQuantity *big.Int, newTimestamp uint64) {
+// UpdateQuantity : update quantity of the order. When dryrun is non-nil, every write
+// this call makes to the order list lands in dryrun's overlay instead of being
+// persisted, so a caller can preview the effect on the book without committing it.
+func (order *Order) UpdateQuantity(orderList *OrderList, newQuantity *big.Int, newTimestamp uint64, dryrun *Dryrun) {
 	if newQuantity.Cmp(order.Item.Quantity) > 0 && !bytes.Equal(orderList.Item.TailOrder, order.Key) {
-		orderList.MoveToTail(order)
+		orderList.MoveToTail(order, dryrun)
 	}
 	// update volume and modified timestamp
 	orderList.Item.Volume = Sub(orderList.Item.Volume, Sub(order.Item.Quantity, newQuantity))
 	order.Item.Timestamp = newTimestamp
 	order.Item.Quantity = CloneBigInt(newQuantity)
 	fmt.Println("QUANTITY", order.Item.Quantity.String())
-	orderList.SaveOrder(order)
-	orderList.Save()
+	orderList.SaveOrder(order, dryrun)
+	orderList.Save(dryrun)
 }
@@ -0,0 +1,128 @@
+package orderbook
+
+import (
+	"encoding/json"
+	"math/big"
+)
+
+// Trade records one fill produced while matching an incoming order against the book:
+// makerKey is the resting order that was hit, takerKey is the incoming order.
+type Trade struct {
+	MakerKey  []byte
+	TakerKey  []byte
+	Price     *big.Int
+	Quantity  *big.Int
+	Timestamp uint64
+}
+
+// OrderBook pairs the two price trees a matching engine needs: Bids holds resting buy
+// orders keyed by price (best bid is Tree.Right), Asks holds resting sells keyed by
+// price (best ask is Tree.Left).
+//
+// Each price level here holds a single resting OrderItem; once OrderList is wired in, a
+// level will instead hold a FIFO queue of orders and ProcessOrder's matching loop will
+// walk that queue too.
+type OrderBook struct {
+	Bids *Tree
+	Asks *Tree
+}
+
+// ProcessOrder matches a new order against the opposite side of the book and returns
+// the trades it produces plus whatever quantity is left unfilled. bidSide is true when
+// the incoming order is a buy, crossing against Asks; false when it is a sell, crossing
+// against Bids.
+//
+// When dryrun is true the match runs against a Dryrun overlay laid over the opposite
+// tree, so the book's persisted state is left untouched and the trades are only a
+// preview of what would happen; when false the overlay is committed, so the match
+// actually happens.
+func (book *OrderBook) ProcessOrder(orderKey []byte, order *OrderItem, bidSide bool, dryrun bool) ([]Trade, *OrderItem) {
+	opposite := book.Asks
+	if !bidSide {
+		opposite = book.Bids
+	}
+
+	// Always matched through a Dryrun overlay, kept on the tree for the whole loop (not
+	// just each Put/Remove call) so that Left/Right reads between fills see this run's
+	// own pending writes. When dryrun is true it is left uncommitted and discarded on
+	// return; when false it is committed below, turning the preview into a real match.
+	dr := NewDryrun(opposite.db)
+	opposite.dryrun = dr
+	defer func() { opposite.dryrun = nil }()
+
+	// Remove/replaceNode update rootKey/size on the live tree directly, outside the
+	// Dryrun overlay (which only buffers node Put/Delete), so a preview that consumes a
+	// level would otherwise leave those two fields corrupted against an unchanged db.
+	// Snapshot them here and restore on the dryrun return, mirroring Txn.Rollback.
+	liveRootKey, liveSize := opposite.rootKey, opposite.size
+
+	remaining := &OrderItem{
+		Timestamp: order.Timestamp,
+		Quantity:  CloneBigInt(order.Quantity),
+		Price:     order.Price,
+	}
+	priceKey := remaining.Price.Bytes()
+
+	var trades []Trade
+	for remaining.Quantity.Sign() > 0 {
+		var level *Node
+		if bidSide {
+			level = opposite.Left()
+		} else {
+			level = opposite.Right()
+		}
+		if level == nil {
+			break
+		}
+
+		compare := opposite.Comparator(level.Key, priceKey)
+		crosses := compare <= 0
+		if !bidSide {
+			crosses = compare >= 0
+		}
+		if !crosses {
+			break
+		}
+
+		resting := &OrderItem{}
+		if err := json.Unmarshal(level.Item.Value, resting); err != nil {
+			break
+		}
+
+		filled := CloneBigInt(remaining.Quantity)
+		if resting.Quantity.Cmp(filled) < 0 {
+			filled = CloneBigInt(resting.Quantity)
+		}
+
+		trades = append(trades, Trade{
+			MakerKey:  level.Key,
+			TakerKey:  orderKey,
+			Price:     resting.Price,
+			Quantity:  filled,
+			Timestamp: remaining.Timestamp,
+		})
+
+		remaining.Quantity = Sub(remaining.Quantity, filled)
+		resting.Quantity = Sub(resting.Quantity, filled)
+
+		if resting.Quantity.Sign() == 0 {
+			opposite.Remove(level.Key, dr)
+			continue
+		}
+
+		encoded, err := json.Marshal(resting)
+		if err != nil {
+			break
+		}
+		if err := opposite.Put(level.Key, encoded, dr); err != nil {
+			break
+		}
+	}
+
+	if dryrun {
+		opposite.rootKey, opposite.size = liveRootKey, liveSize
+		return trades, remaining
+	}
+	dr.Commit()
+	return trades, remaining
+}
@@ -15,6 +15,8 @@ type Tree struct {
 	db          *BatchDatabase
 	rootKey     []byte
 	size        uint64
+	overlay     *txnOverlay
+	dryrun      *Dryrun
 	Comparator  Comparator
 	FormatBytes FormatBytes
 }
@@ -52,7 +54,15 @@ func (tree *Tree) SetRootKey(key []byte, size uint64) {
 
 // Put inserts node into the tree.
 // Key should adhere to the comparator's type assertion, otherwise method panics.
-func (tree *Tree) Put(key []byte, value []byte) error {
+// When dryrun is non-nil, every node write this call makes lands in dryrun's overlay
+// instead of the tree's BatchDatabase.
+func (tree *Tree) Put(key []byte, value []byte, dryrun *Dryrun) error {
+	if dryrun != nil {
+		prev := tree.dryrun
+		tree.dryrun = dryrun
+		defer func() { tree.dryrun = prev }()
+	}
+
 	var insertedNode *Node
 	if tree.IsEmptyKey(tree.rootKey) {
 		// Assert key is of comparator's type for initial tree
@@ -114,6 +124,27 @@ func (tree *Tree) Put(key []byte, value []byte) error {
 
 func (tree *Tree) GetNode(key []byte) (*Node, error) {
 
+	if tree.dryrun != nil {
+		item := &Item{}
+		val, found := tree.dryrun.lookup(key, item)
+		if found {
+			if val == nil {
+				return nil, nil
+			}
+			return &Node{Key: key, Item: val.(*Item)}, nil
+		}
+	}
+
+	if tree.overlay != nil {
+		k := string(key)
+		if tree.overlay.deleted[k] {
+			return nil, nil
+		}
+		if node, ok := tree.overlay.nodes[k]; ok {
+			return node, nil
+		}
+	}
+
 	item := &Item{}
 
 	val, err := tree.db.Get(key, item)
@@ -146,7 +177,15 @@ func (tree *Tree) Get(key []byte) (value []byte, found bool) {
 
 // Remove remove the node from the tree by key.
 // Key should adhere to the comparator's type assertion, otherwise method panics.
-func (tree *Tree) Remove(key []byte) {
+// When dryrun is non-nil, every node write this call makes lands in dryrun's overlay
+// instead of the tree's BatchDatabase.
+func (tree *Tree) Remove(key []byte, dryrun *Dryrun) {
+	if dryrun != nil {
+		prev := tree.dryrun
+		tree.dryrun = dryrun
+		defer func() { tree.dryrun = prev }()
+	}
+
 	var child *Node
 	node, err := tree.GetNode(key)
 
@@ -531,6 +570,17 @@ func (tree *Tree) Save(node *Node) error {
 	// value, err := json.Marshal(node.Item)
 	// tree.assertNotNull(node, hex.EncodeToString(node.Key))
 
+	if tree.dryrun != nil {
+		return tree.dryrun.Put(node.Key, node.Item)
+	}
+
+	if tree.overlay != nil {
+		k := string(node.Key)
+		delete(tree.overlay.deleted, k)
+		tree.overlay.nodes[k] = node
+		return nil
+	}
+
 	return tree.db.Put(node.Key, node.Item)
 
 }
@@ -689,5 +739,18 @@ func (tree *Tree) deleteNode(node *Node, force bool) {
 	if tree.size > 1 && tree.Comparator(node.Key, tree.rootKey) == 0 {
 		return
 	}
+
+	if tree.dryrun != nil {
+		tree.dryrun.Delete(node.Key, force)
+		return
+	}
+
+	if tree.overlay != nil {
+		k := string(node.Key)
+		delete(tree.overlay.nodes, k)
+		tree.overlay.deleted[k] = true
+		return
+	}
+
 	tree.db.Delete(node.Key, force)
 }
@@ -0,0 +1,168 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import "fmt"
+
+// txnOverlay buffers node writes and deletes made inside a write Txn, keyed by node
+// key. Reads against the tree consult it before falling through to BatchDatabase, so
+// Rollback can discard a failed match by simply dropping the overlay, never having
+// touched the persisted red-black structure.
+type txnOverlay struct {
+	nodes   map[string]*Node
+	deleted map[string]bool
+}
+
+func newTxnOverlay() *txnOverlay {
+	return &txnOverlay{
+		nodes:   make(map[string]*Node),
+		deleted: make(map[string]bool),
+	}
+}
+
+// Txn is a transaction over a Tree, modeled on buntdb's db.Update/db.View: a write Txn
+// installs an overlay so Put/Remove buffer their node writes instead of hitting
+// BatchDatabase directly, and a read-only Txn reads through snapshot, an independent
+// *Tree pinned to the tree's rootKey/size at Begin, so its reads and iterators are not
+// disturbed by a concurrent writer moving the live tree's root.
+type Txn struct {
+	tree     *Tree
+	writable bool
+	overlay  *txnOverlay
+	snapshot *Tree
+	rootKey  []byte
+	size     uint64
+	done     bool
+}
+
+// Begin starts a transaction against the tree. Only one write transaction may be open
+// on a tree at a time; any number of read-only transactions may be open concurrently,
+// each with its own pinned snapshot.
+func (tree *Tree) Begin(writable bool) (*Txn, error) {
+	txn := &Txn{
+		tree:     tree,
+		writable: writable,
+		rootKey:  tree.rootKey,
+		size:     tree.size,
+	}
+	if writable {
+		if tree.overlay != nil {
+			return nil, fmt.Errorf("orderbook: tree already has an open write transaction")
+		}
+		txn.overlay = newTxnOverlay()
+		tree.overlay = txn.overlay
+		return txn, nil
+	}
+
+	txn.snapshot = &Tree{
+		db:          tree.db,
+		rootKey:     tree.rootKey,
+		size:        tree.size,
+		Comparator:  tree.Comparator,
+		FormatBytes: tree.FormatBytes,
+	}
+	return txn, nil
+}
+
+func (txn *Txn) checkOpen() {
+	if txn.done {
+		panic("orderbook: use of Txn after Commit/Rollback")
+	}
+}
+
+// view returns the Tree reads should run against: the pinned snapshot for a read-only
+// Txn, or the live tree (so writes already in this Txn's overlay are visible) for a
+// writable one.
+func (txn *Txn) view() *Tree {
+	if txn.snapshot != nil {
+		return txn.snapshot
+	}
+	return txn.tree
+}
+
+// Put mirrors Tree.Put; inside a write Txn its node writes land in the overlay. It
+// returns an error rather than mutating the tree when called on a read-only Txn.
+func (txn *Txn) Put(key []byte, value []byte) error {
+	txn.checkOpen()
+	if !txn.writable {
+		return fmt.Errorf("orderbook: cannot Put in a read-only transaction")
+	}
+	return txn.tree.Put(key, value, nil)
+}
+
+// Get mirrors Tree.Get, resolving through the overlay first when inside a write Txn, or
+// against the pinned snapshot when read-only.
+func (txn *Txn) Get(key []byte) (value []byte, found bool) {
+	txn.checkOpen()
+	return txn.view().Get(key)
+}
+
+// Remove mirrors Tree.Remove. It returns an error rather than mutating the tree when
+// called on a read-only Txn.
+func (txn *Txn) Remove(key []byte) error {
+	txn.checkOpen()
+	if !txn.writable {
+		return fmt.Errorf("orderbook: cannot Remove in a read-only transaction")
+	}
+	txn.tree.Remove(key, nil)
+	return nil
+}
+
+// Floor mirrors Tree.Floor, against the pinned snapshot when read-only.
+func (txn *Txn) Floor(key []byte) (floor *Node, found bool) {
+	txn.checkOpen()
+	return txn.view().Floor(key)
+}
+
+// Ceiling mirrors Tree.Ceiling, against the pinned snapshot when read-only.
+func (txn *Txn) Ceiling(key []byte) (ceiling *Node, found bool) {
+	txn.checkOpen()
+	return txn.view().Ceiling(key)
+}
+
+// Iterator mirrors Tree.Iterator. For a read-only Txn it walks from the rootKey/size
+// pinned at Begin; only those two are pinned, so node records that a writer commits to
+// the BatchDatabase after Begin are read as they are at the time of each step.
+func (txn *Txn) Iterator() *Iterator {
+	txn.checkOpen()
+	return txn.view().Iterator()
+}
+
+// Commit flushes a write transaction's overlay through the tree's BatchDatabase and
+// commits the batch; it is a no-op for read-only transactions. The tree's rootKey/size
+// are already current on the live Tree, and Commit writes no root pointer to the
+// BatchDatabase.
+func (txn *Txn) Commit() error {
+	txn.checkOpen()
+	txn.done = true
+	if !txn.writable {
+		return nil
+	}
+	defer func() { txn.tree.overlay = nil }()
+
+	for k, node := range txn.overlay.nodes {
+		if err := txn.tree.db.Put([]byte(k), node.Item); err != nil {
+			return err
+		}
+	}
+	for k := range txn.overlay.deleted {
+		txn.tree.db.Delete([]byte(k), true)
+	}
+	return txn.tree.db.Commit()
+}
+
+// Rollback discards a write transaction's overlay and restores the tree's rootKey/size
+// to what they were at Begin; read-only transactions have nothing to discard.
+func (txn *Txn) Rollback() error {
+	txn.checkOpen()
+	txn.done = true
+	if !txn.writable {
+		return nil
+	}
+	txn.tree.overlay = nil
+	txn.tree.rootKey = txn.rootKey
+	txn.tree.size = txn.size
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2019, Agiletech Viet Nam. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package orderbook
+
+import "testing"
+
+func TestTxnReadOnlyRejectsWrites(t *testing.T) {
+	tree := &Tree{}
+
+	txn, err := tree.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin(false): %v", err)
+	}
+
+	if err := txn.Put([]byte("k"), []byte("v")); err == nil {
+		t.Error("Put on a read-only Txn should have returned an error")
+	}
+	if err := txn.Remove([]byte("k")); err == nil {
+		t.Error("Remove on a read-only Txn should have returned an error")
+	}
+}
+
+func TestTxnReadOnlySnapshotsRootAtBegin(t *testing.T) {
+	tree := &Tree{rootKey: []byte("root-at-begin"), size: 3}
+
+	txn, err := tree.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin(false): %v", err)
+	}
+
+	// A concurrent writer moves the live tree's root after the read txn started.
+	tree.rootKey = []byte("root-after-begin")
+	tree.size = 5
+
+	view := txn.view()
+	if string(view.rootKey) != "root-at-begin" {
+		t.Errorf("read-only Txn view sees rootKey %q, want the pinned %q", view.rootKey, "root-at-begin")
+	}
+	if view.size != 3 {
+		t.Errorf("read-only Txn view sees size %d, want the pinned 3", view.size)
+	}
+}
+
+func TestTxnWritableViewIsLiveTree(t *testing.T) {
+	tree := &Tree{rootKey: []byte("initial")}
+
+	txn, err := tree.Begin(true)
+	if err != nil {
+		t.Fatalf("Begin(true): %v", err)
+	}
+	if txn.view() != tree {
+		t.Error("a writable Txn's view should be the live tree, so its overlay is visible to reads")
+	}
+}